@@ -0,0 +1,212 @@
+// Package a2s implements backend.Backend by querying a Squad server
+// directly over the Source engine A2S_INFO/A2S_PLAYER UDP protocol. It
+// talks straight to the game server, so it needs no auth and no rate
+// limiting, unlike the battlemetrics backend.
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/yggdrion/squad-exporter/backend"
+)
+
+const requestHeader uint32 = 0xFFFFFFFF
+
+var infoPayload = append([]byte{0x54}, []byte("Source Engine Query\x00")...)
+
+// Backend queries servers over A2S_INFO/A2S_PLAYER.
+type Backend struct {
+	timeout time.Duration
+}
+
+// New creates an A2S backend with a conservative UDP timeout.
+func New() *Backend {
+	return &Backend{timeout: 5 * time.Second}
+}
+
+// FetchServer implements backend.Backend.
+func (b *Backend) FetchServer(ctx context.Context, server backend.Server) (backend.ServerState, error) {
+	conn, err := net.DialTimeout("udp", server.Address, b.timeout)
+	if err != nil {
+		return backend.ServerState{}, fmt.Errorf("failed to dial A2S server %s: %w", server.Name, err)
+	}
+	defer conn.Close()
+	stop := backend.WatchContext(ctx, conn)
+	defer stop()
+	_ = conn.SetDeadline(time.Now().Add(b.timeout))
+
+	name, mapName, err := b.queryInfo(conn)
+	if err != nil {
+		return backend.ServerState{}, fmt.Errorf("A2S_INFO query failed for server %s: %w", server.Name, err)
+	}
+
+	players, err := b.queryPlayerCount(conn)
+	if err != nil {
+		return backend.ServerState{}, fmt.Errorf("A2S_PLAYER query failed for server %s: %w", server.Name, err)
+	}
+
+	return backend.ServerState{
+		FullName: name,
+		Players:  players,
+		Map:      mapName,
+	}, nil
+}
+
+func (b *Backend) queryInfo(conn net.Conn) (name, mapName string, err error) {
+	r, err := b.sendInfoRequest(conn, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	header, err := r.ReadByte()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read A2S_INFO response header: %w", err)
+	}
+
+	// Since Valve's 2020 anti-amplification change, servers reply to the
+	// initial A2S_INFO request with a challenge (as A2S_PLAYER already
+	// handles below) instead of the info packet directly; the request
+	// must be resent with the challenge appended.
+	if header == 'A' {
+		var challenge [4]byte
+		if _, err := io.ReadFull(r, challenge[:]); err != nil {
+			return "", "", fmt.Errorf("failed to read A2S_INFO challenge: %w", err)
+		}
+
+		r, err = b.sendInfoRequest(conn, challenge[:])
+		if err != nil {
+			return "", "", err
+		}
+		header, err = r.ReadByte()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read A2S_INFO response header: %w", err)
+		}
+	}
+
+	if header != 'I' {
+		return "", "", fmt.Errorf("unexpected A2S_INFO response header %#x", header)
+	}
+	if _, err := r.ReadByte(); err != nil { // protocol version, unused
+		return "", "", fmt.Errorf("failed to read protocol version: %w", err)
+	}
+
+	name, err = readCString(r)
+	if err != nil {
+		return "", "", err
+	}
+	mapName, err = readCString(r)
+	if err != nil {
+		return "", "", err
+	}
+	return name, mapName, nil
+}
+
+// sendInfoRequest sends an A2S_INFO request, optionally with a challenge
+// appended (required when retrying after a challenge response), and returns
+// a reader over the response payload with the 0xFFFFFFFF header stripped.
+func (b *Backend) sendInfoRequest(conn net.Conn, challenge []byte) (*bytes.Reader, error) {
+	req := new(bytes.Buffer)
+	_ = binary.Write(req, binary.LittleEndian, requestHeader)
+	req.Write(infoPayload)
+	if challenge != nil {
+		req.Write(challenge)
+	}
+
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send A2S_INFO request: %w", err)
+	}
+
+	buf := make([]byte, 1400)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read A2S_INFO response: %w", err)
+	}
+	if n < 5 {
+		return nil, fmt.Errorf("A2S_INFO response too short")
+	}
+
+	return bytes.NewReader(buf[4:n]), nil
+}
+
+func (b *Backend) queryPlayerCount(conn net.Conn) (int, error) {
+	challenge, err := b.requestChallenge(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	req := new(bytes.Buffer)
+	_ = binary.Write(req, binary.LittleEndian, requestHeader)
+	req.WriteByte(0x55)
+	_ = binary.Write(req, binary.LittleEndian, challenge)
+
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to send A2S_PLAYER request: %w", err)
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read A2S_PLAYER response: %w", err)
+	}
+	if n < 6 {
+		return 0, fmt.Errorf("A2S_PLAYER response too short")
+	}
+
+	r := bytes.NewReader(buf[4:n])
+	header, err := r.ReadByte()
+	if err != nil || header != 'D' {
+		return 0, fmt.Errorf("unexpected A2S_PLAYER response header %#x", header)
+	}
+
+	count, err := r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read player count: %w", err)
+	}
+	return int(count), nil
+}
+
+// requestChallenge performs the A2S_PLAYER challenge handshake: an initial
+// request with no challenge gets back a challenge number the real request
+// must echo.
+func (b *Backend) requestChallenge(conn net.Conn) (uint32, error) {
+	req := new(bytes.Buffer)
+	_ = binary.Write(req, binary.LittleEndian, requestHeader)
+	req.WriteByte(0x55)
+	_ = binary.Write(req, binary.LittleEndian, requestHeader)
+
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to send challenge request: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read challenge response: %w", err)
+	}
+	if n < 9 || buf[4] != 'A' {
+		return 0, fmt.Errorf("unexpected challenge response header")
+	}
+
+	return binary.LittleEndian.Uint32(buf[5:9]), nil
+}
+
+func readCString(r *bytes.Reader) (string, error) {
+	var out []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("failed to read string: %w", err)
+		}
+		if c == 0 {
+			break
+		}
+		out = append(out, c)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,136 @@
+package a2s
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadCString(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "simple", input: []byte("hello\x00"), want: "hello"},
+		{name: "empty", input: []byte("\x00"), want: ""},
+		{name: "trailing data ignored", input: []byte("map_name\x00extra"), want: "map_name"},
+		{name: "unterminated", input: []byte("no terminator"), wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readCString(bytes.NewReader(tc.input))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// buildChallengeResponse builds the A2S_PLAYER/A2S_INFO challenge response
+// Valve's anti-amplification change requires servers to send instead of the
+// real response to the initial request.
+func buildChallengeResponse(challenge uint32) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, requestHeader)
+	buf.WriteByte('A')
+	_ = binary.Write(buf, binary.LittleEndian, challenge)
+	return buf.Bytes()
+}
+
+func buildInfoResponse(name, mapName string) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, requestHeader)
+	buf.WriteByte('I')
+	buf.WriteByte(0x11) // protocol version, unused by queryInfo
+	buf.WriteString(name)
+	buf.WriteByte(0)
+	buf.WriteString(mapName)
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+// TestQueryInfoChallengeRetry covers the branch fixed in 035688f: servers
+// that challenge the initial A2S_INFO request must be retried with the
+// challenge appended before they return the real info packet.
+func TestQueryInfoChallengeRetry(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- func() error {
+			buf := make([]byte, 1400)
+			if _, err := server.Read(buf); err != nil { // initial A2S_INFO request
+				return err
+			}
+			if _, err := server.Write(buildChallengeResponse(0xAABBCCDD)); err != nil {
+				return err
+			}
+
+			if _, err := server.Read(buf); err != nil { // retried request with challenge
+				return err
+			}
+			_, err := server.Write(buildInfoResponse("Squad Server", "Narva"))
+			return err
+		}()
+	}()
+
+	b := &Backend{timeout: 2 * time.Second}
+	name, mapName, err := b.queryInfo(client)
+	if err != nil {
+		t.Fatalf("queryInfo returned error: %v", err)
+	}
+	if name != "Squad Server" || mapName != "Narva" {
+		t.Fatalf("got name=%q map=%q, want name=%q map=%q", name, mapName, "Squad Server", "Narva")
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatalf("fake server failed: %v", err)
+	}
+}
+
+// TestQueryInfoDirectResponse covers the (now-rare) case of a server that
+// answers A2S_INFO directly without a challenge.
+func TestQueryInfoDirectResponse(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- func() error {
+			buf := make([]byte, 1400)
+			if _, err := server.Read(buf); err != nil {
+				return err
+			}
+			_, err := server.Write(buildInfoResponse("Direct Server", "Yehorivka"))
+			return err
+		}()
+	}()
+
+	b := &Backend{timeout: 2 * time.Second}
+	name, mapName, err := b.queryInfo(client)
+	if err != nil {
+		t.Fatalf("queryInfo returned error: %v", err)
+	}
+	if name != "Direct Server" || mapName != "Yehorivka" {
+		t.Fatalf("got name=%q map=%q, want name=%q map=%q", name, mapName, "Direct Server", "Yehorivka")
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatalf("fake server failed: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Package backend defines the pluggable interface the collector uses to
+// fetch a Squad server's live state, and the types shared by every
+// implementation (BattleMetrics, Steam A2S, SquadJS RCON).
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Type identifies which backend implementation a Server entry uses.
+type Type string
+
+const (
+	// TypeBattleMetrics fetches state from the BattleMetrics API. This is
+	// the default when a servers.json entry has no "type" set.
+	TypeBattleMetrics Type = "battlemetrics"
+	// TypeA2S queries the game server directly over the Source engine
+	// A2S_INFO/A2S_PLAYER UDP protocol.
+	TypeA2S Type = "a2s"
+	// TypeRCON queries the game server's Source-RCON interface for server
+	// info and the active player count; no per-squad or per-player state is
+	// collected.
+	TypeRCON Type = "rcon"
+)
+
+// Server is one entry of servers.json. Only the fields relevant to its Type
+// need to be set; the rest are ignored.
+type Server struct {
+	Name string `json:"Name"`
+	Type Type   `json:"type,omitempty"`
+
+	// URL is used by the battlemetrics backend.
+	URL string `json:"Url,omitempty"`
+
+	// Address is the host:port used by the a2s and rcon backends.
+	Address string `json:"address,omitempty"`
+
+	// RCONPassword authenticates against the rcon backend.
+	RCONPassword string `json:"rcon_password,omitempty"`
+}
+
+// ServerState is the backend-agnostic snapshot of a server's live state,
+// regardless of which backend produced it.
+type ServerState struct {
+	FullName      string
+	Players       int
+	Map           string
+	GameMode      string
+	SquadPlayTime int
+	SquadTeamOne  string
+	SquadTeamTwo  string
+}
+
+// Backend fetches the current state of a single server.
+type Backend interface {
+	FetchServer(ctx context.Context, server Server) (ServerState, error)
+}
+
+// UnsupportedTypeError is returned when a Server names a Type with no
+// registered Backend.
+type UnsupportedTypeError struct {
+	Type Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported backend type %q", e.Type)
+}
+
+// WatchContext closes conn as soon as ctx is done, so a blocking Read/Write
+// on conn is interrupted instead of running past ctx's deadline or
+// cancellation. Backends that talk over raw net.Conn (a2s, rcon) have no
+// other way to make their I/O respect ctx, since neither protocol is
+// request/response over net/http. Call the returned stop func once done
+// with conn to release the watcher goroutine.
+func WatchContext(ctx context.Context, conn io.Closer) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
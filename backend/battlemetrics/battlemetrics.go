@@ -0,0 +1,279 @@
+// Package battlemetrics implements backend.Backend against the
+// BattleMetrics API. It is the only backend that depends on a shared,
+// rate-limited quota, so it owns its own limiter and request
+// de-duplication rather than relying on the collector for either.
+package battlemetrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yggdrion/squad-exporter/backend"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// BurstCapacity is the BattleMetrics documented burst capacity: 60
+// requests/minute (1/sec refill), 15 requests/second burst.
+const BurstCapacity = 15
+
+// Backend fetches server state from the BattleMetrics API.
+type Backend struct {
+	httpClient *http.Client
+	limiter    *adaptiveLimiter
+	sfGroup    singleflight.Group
+}
+
+// New creates a BattleMetrics backend with an adaptive rate limiter that
+// starts at the documented refill rate of 1 request/second.
+func New() *Backend {
+	return &Backend{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		limiter:    newAdaptiveLimiter(),
+	}
+}
+
+// response is the BattleMetrics API response structure (simplified).
+type response struct {
+	Data struct {
+		Attributes struct {
+			Name    string `json:"name"`
+			Players int    `json:"players"`
+			Details struct {
+				Map           string `json:"map"`
+				GameMode      string `json:"gameMode"`
+				SquadPlayTime int    `json:"squad_playTime"`
+				SquadTeamOne  string `json:"squad_teamOne"`
+				SquadTeamTwo  string `json:"squad_teamTwo"`
+			} `json:"details"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// FetchServer implements backend.Backend. Concurrent or overlapping calls
+// for the same URL (e.g. two server entries pointing at the same API URL,
+// or overlapping scrape cycles) are deduplicated via singleflight so only
+// one request is ever in flight per URL.
+func (b *Backend) FetchServer(ctx context.Context, server backend.Server) (backend.ServerState, error) {
+	v, err, _ := b.sfGroup.Do(server.URL, func() (interface{}, error) {
+		if err := b.limiter.wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limiter error for server %s: %w", server.Name, err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request for server %s: %w", server.Name, err)
+		}
+
+		start := time.Now()
+		resp, err := b.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch data for server %s: %w", server.Name, err)
+		}
+		defer func() {
+			if closeErr := resp.Body.Close(); closeErr != nil {
+				log.Printf("Failed to close response body for server %s: %v", server.Name, closeErr)
+			}
+		}()
+
+		if resp.StatusCode != http.StatusOK {
+			// Handle rate limiting specifically
+			if resp.StatusCode == 429 || resp.StatusCode == 503 {
+				retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+				b.limiter.recordRateLimited(retryAfter)
+
+				log.Printf("⚠️  Rate limit hit for server %s (HTTP %d), backing off to %.2f req/s",
+					server.Name, resp.StatusCode, b.limiter.currentRPS())
+
+				return nil, fmt.Errorf("rate limit exceeded for server %s (HTTP %d)", server.Name, resp.StatusCode)
+			}
+
+			return nil, fmt.Errorf("unexpected status code %d for server %s", resp.StatusCode, server.Name)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body for server %s: %w", server.Name, err)
+		}
+
+		var bmResp response
+		if err := json.Unmarshal(body, &bmResp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response for server %s: %w", server.Name, err)
+		}
+
+		b.limiter.recordSuccess(time.Since(start))
+
+		attrs := bmResp.Data.Attributes
+		return backend.ServerState{
+			FullName:      attrs.Name,
+			Players:       attrs.Players,
+			Map:           attrs.Details.Map,
+			GameMode:      attrs.Details.GameMode,
+			SquadPlayTime: attrs.Details.SquadPlayTime,
+			SquadTeamOne:  attrs.Details.SquadTeamOne,
+			SquadTeamTwo:  attrs.Details.SquadTeamTwo,
+		}, nil
+	})
+	if err != nil {
+		return backend.ServerState{}, err
+	}
+	return v.(backend.ServerState), nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds, returning 0
+// if it is absent or malformed.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	secs, err := strconv.Atoi(header)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+const (
+	minRPS           = 0.1
+	maxRPS           = 1.0 // documented refill rate; burst stays at BurstCapacity
+	increaseStep     = 0.1
+	successesToGrow  = 10
+	latencyEWMAAlpha = 0.2
+)
+
+var (
+	currentRPSGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "squad_ratelimit_current_rps",
+			Help: "Current refill rate of the BattleMetrics adaptive rate limiter, in requests/second",
+		},
+	)
+
+	ewmaLatencyGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "squad_ratelimit_ewma_latency_seconds",
+			Help: "Exponentially weighted moving average of successful BattleMetrics request latency",
+		},
+	)
+
+	backoffSecondsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "squad_ratelimit_backoff_seconds",
+			Help: "Retry-After duration honored by the most recent BattleMetrics 429/503 response",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(currentRPSGauge, ewmaLatencyGauge, backoffSecondsGauge)
+}
+
+// adaptiveLimiter throttles BattleMetrics requests with an AIMD controller:
+// multiplicative decrease on 429/503 (honoring Retry-After exactly when the
+// server sends one), additive increase after a sustained run of successes.
+// It replaces the fixed 1 req/sec limiter so a single server's rate limit no
+// longer has to be babysat by the collector.
+type adaptiveLimiter struct {
+	mu           sync.Mutex
+	limiter      *rate.Limiter
+	rps          float64
+	successRun   int
+	latencyEWMA  time.Duration
+	backoffUntil time.Time
+}
+
+func newAdaptiveLimiter() *adaptiveLimiter {
+	a := &adaptiveLimiter{rps: maxRPS}
+	a.limiter = rate.NewLimiter(rate.Limit(a.rps), BurstCapacity)
+	currentRPSGauge.Set(a.rps)
+	return a
+}
+
+// wait blocks until a request slot is available, honoring any active
+// Retry-After backoff ahead of the token bucket itself.
+func (a *adaptiveLimiter) wait(ctx context.Context) error {
+	a.mu.Lock()
+	backoffUntil := a.backoffUntil
+	a.mu.Unlock()
+
+	if wait := time.Until(backoffUntil); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return a.limiter.Wait(ctx)
+}
+
+// currentRPS returns the limiter's current refill rate.
+func (a *adaptiveLimiter) currentRPS() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rps
+}
+
+// recordSuccess folds a successful request's latency into the EWMA and,
+// once successesToGrow successes have passed without a rate limit, grows
+// the rate by increaseStep up to maxRPS.
+func (a *adaptiveLimiter) recordSuccess(latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.latencyEWMA == 0 {
+		a.latencyEWMA = latency
+	} else {
+		a.latencyEWMA = time.Duration(latencyEWMAAlpha*float64(latency) + (1-latencyEWMAAlpha)*float64(a.latencyEWMA))
+	}
+	ewmaLatencyGauge.Set(a.latencyEWMA.Seconds())
+
+	a.successRun++
+	if a.successRun < successesToGrow {
+		return
+	}
+	a.successRun = 0
+
+	if a.rps < maxRPS {
+		a.rps += increaseStep
+		if a.rps > maxRPS {
+			a.rps = maxRPS
+		}
+		a.limiter.SetLimit(rate.Limit(a.rps))
+		currentRPSGauge.Set(a.rps)
+	}
+}
+
+// recordRateLimited multiplicatively decreases the rate and, if the server
+// sent an explicit Retry-After, blocks all requests until it elapses.
+func (a *adaptiveLimiter) recordRateLimited(retryAfter time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.successRun = 0
+	a.rps /= 2
+	if a.rps < minRPS {
+		a.rps = minRPS
+	}
+	a.limiter.SetLimit(rate.Limit(a.rps))
+	currentRPSGauge.Set(a.rps)
+
+	backoffSecondsGauge.Set(retryAfter.Seconds())
+	if retryAfter <= 0 {
+		return
+	}
+	until := time.Now().Add(retryAfter)
+	if until.After(a.backoffUntil) {
+		a.backoffUntil = until
+	}
+}
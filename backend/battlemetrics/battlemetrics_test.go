@@ -0,0 +1,81 @@
+package battlemetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterDecreasesOnRateLimit(t *testing.T) {
+	a := newAdaptiveLimiter()
+	if got := a.currentRPS(); got != maxRPS {
+		t.Fatalf("expected initial rps %v, got %v", maxRPS, got)
+	}
+
+	a.recordRateLimited(0)
+	if got, want := a.currentRPS(), maxRPS/2; got != want {
+		t.Fatalf("expected rps to halve to %v after one rate limit, got %v", want, got)
+	}
+
+	// Repeated rate limits must floor at minRPS, never go to zero or negative.
+	for i := 0; i < 10; i++ {
+		a.recordRateLimited(0)
+	}
+	if got := a.currentRPS(); got != minRPS {
+		t.Fatalf("expected rps to floor at %v, got %v", minRPS, got)
+	}
+}
+
+func TestAdaptiveLimiterIncreasesAfterSustainedSuccess(t *testing.T) {
+	a := newAdaptiveLimiter()
+	a.recordRateLimited(0) // rps is now maxRPS/2, leaving room to grow
+
+	base := a.currentRPS()
+	for i := 0; i < successesToGrow-1; i++ {
+		a.recordSuccess(10 * time.Millisecond)
+	}
+	if got := a.currentRPS(); got != base {
+		t.Fatalf("rps should not grow before successesToGrow successes, got %v want %v", got, base)
+	}
+
+	a.recordSuccess(10 * time.Millisecond)
+	if got, want := a.currentRPS(), base+increaseStep; got != want {
+		t.Fatalf("expected rps to grow by %v to %v after a sustained success run, got %v", increaseStep, want, got)
+	}
+}
+
+func TestAdaptiveLimiterNeverExceedsMaxRPS(t *testing.T) {
+	a := newAdaptiveLimiter() // already at maxRPS
+	for i := 0; i < successesToGrow*3; i++ {
+		a.recordSuccess(time.Millisecond)
+	}
+	if got := a.currentRPS(); got != maxRPS {
+		t.Fatalf("expected rps to stay capped at %v, got %v", maxRPS, got)
+	}
+}
+
+func TestAdaptiveLimiterHonorsRetryAfter(t *testing.T) {
+	a := newAdaptiveLimiter()
+	backoff := 100 * time.Millisecond
+	a.recordRateLimited(backoff)
+
+	start := time.Now()
+	if err := a.wait(context.Background()); err != nil {
+		t.Fatalf("wait returned unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < backoff {
+		t.Fatalf("wait returned after %v, expected it to honor the %v Retry-After backoff", elapsed, backoff)
+	}
+}
+
+func TestAdaptiveLimiterWaitRespectsContextCancellation(t *testing.T) {
+	a := newAdaptiveLimiter()
+	a.recordRateLimited(time.Hour) // a backoff long enough to outlast the test
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := a.wait(ctx); err == nil {
+		t.Fatal("expected wait to return an error once its context is done, got nil")
+	}
+}
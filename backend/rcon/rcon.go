@@ -0,0 +1,201 @@
+// Package rcon implements backend.Backend against Squad's Source-RCON
+// interface. It reads the server name, map, game mode and teams from
+// ShowServerInfo and counts active players from ListPlayers; it collects no
+// per-squad or per-player state, and SquadPlayTime is always left at zero.
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/yggdrion/squad-exporter/backend"
+)
+
+const (
+	packetTypeAuth               = 3
+	packetTypeAuthResponse       = 2
+	packetTypeExecCommand        = 2
+	packetIDAuth           int32 = 1
+	packetIDCommand        int32 = 2
+	packetIDTerminator     int32 = 3
+)
+
+// Backend queries servers over Squad's Source-RCON interface.
+type Backend struct {
+	timeout time.Duration
+}
+
+// New creates an RCON backend with a conservative connection timeout.
+func New() *Backend {
+	return &Backend{timeout: 5 * time.Second}
+}
+
+// FetchServer implements backend.Backend.
+func (b *Backend) FetchServer(ctx context.Context, server backend.Server) (backend.ServerState, error) {
+	conn, err := net.DialTimeout("tcp", server.Address, b.timeout)
+	if err != nil {
+		return backend.ServerState{}, fmt.Errorf("failed to dial RCON server %s: %w", server.Name, err)
+	}
+	defer conn.Close()
+	stop := backend.WatchContext(ctx, conn)
+	defer stop()
+	_ = conn.SetDeadline(time.Now().Add(b.timeout))
+
+	if err := authenticate(conn, server.RCONPassword); err != nil {
+		return backend.ServerState{}, fmt.Errorf("RCON auth failed for server %s: %w", server.Name, err)
+	}
+
+	info, err := sendCommand(conn, "ShowServerInfo")
+	if err != nil {
+		return backend.ServerState{}, fmt.Errorf("ShowServerInfo failed for server %s: %w", server.Name, err)
+	}
+
+	players, err := sendCommand(conn, "ListPlayers")
+	if err != nil {
+		return backend.ServerState{}, fmt.Errorf("ListPlayers failed for server %s: %w", server.Name, err)
+	}
+
+	state := parseServerInfo(info)
+	state.Players = countPlayers(players)
+	return state, nil
+}
+
+// serverInfoResponse mirrors the JSON Squad's ShowServerInfo RCON command
+// returns.
+type serverInfoResponse struct {
+	ServerName string `json:"ServerName_s"`
+	MapName    string `json:"MapName_s"`
+	GameMode   string `json:"GameMode_s"`
+	TeamOne    string `json:"TeamOne_s"`
+	TeamTwo    string `json:"TeamTwo_s"`
+}
+
+func parseServerInfo(body string) backend.ServerState {
+	var info serverInfoResponse
+	if err := json.Unmarshal([]byte(body), &info); err != nil {
+		return backend.ServerState{}
+	}
+	return backend.ServerState{
+		FullName:     info.ServerName,
+		Map:          info.MapName,
+		GameMode:     info.GameMode,
+		SquadTeamOne: info.TeamOne,
+		SquadTeamTwo: info.TeamTwo,
+	}
+}
+
+// countPlayers counts the "ID: ..." lines under ListPlayers' "Active
+// Players" section, one per connected player. ListPlayers also lists
+// recently-disconnected players under a second "Recently Disconnected"
+// section with the same "ID: ..." line format, so counting stops there
+// instead of scanning the whole response.
+func countPlayers(body string) int {
+	count := 0
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "----- Recently Disconnected") {
+			break
+		}
+		if strings.HasPrefix(line, "ID:") {
+			count++
+		}
+	}
+	return count
+}
+
+func authenticate(conn net.Conn, password string) error {
+	if err := writePacket(conn, packetIDAuth, packetTypeAuth, password); err != nil {
+		return err
+	}
+
+	// The server always sends an empty SERVERDATA_RESPONSE_VALUE ahead of
+	// the real auth response.
+	if _, _, _, err := readPacket(conn); err != nil {
+		return fmt.Errorf("failed to read auth ack: %w", err)
+	}
+
+	id, packetType, _, err := readPacket(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read auth response: %w", err)
+	}
+	// The server echoes back id == -1 when authentication fails.
+	if packetType != packetTypeAuthResponse || id == -1 {
+		return fmt.Errorf("authentication rejected")
+	}
+	return nil
+}
+
+// sendCommand sends command and reassembles its response. Source-RCON
+// splits large responses (e.g. ListPlayers on a full server) across
+// multiple packets sharing the command's id, with no explicit end marker.
+// The standard workaround is to follow the command with an empty sentinel
+// packet of its own id: the server processes packets in order, so its
+// (empty) response marks the end of the real one.
+func sendCommand(conn net.Conn, command string) (string, error) {
+	if err := writePacket(conn, packetIDCommand, packetTypeExecCommand, command); err != nil {
+		return "", err
+	}
+	if err := writePacket(conn, packetIDTerminator, packetTypeExecCommand, ""); err != nil {
+		return "", err
+	}
+
+	var body strings.Builder
+	for {
+		id, _, chunk, err := readPacket(conn)
+		if err != nil {
+			return "", err
+		}
+		if id == packetIDTerminator {
+			break
+		}
+		if id == packetIDCommand {
+			body.WriteString(chunk)
+		}
+	}
+	return body.String(), nil
+}
+
+// writePacket encodes and sends one Source-RCON packet.
+func writePacket(conn net.Conn, id, packetType int32, body string) error {
+	payload := new(bytes.Buffer)
+	_ = binary.Write(payload, binary.LittleEndian, id)
+	_ = binary.Write(payload, binary.LittleEndian, packetType)
+	payload.WriteString(body)
+	payload.WriteByte(0) // body terminator
+	payload.WriteByte(0) // packet terminator
+
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, int32(payload.Len()))
+	buf.Write(payload.Bytes())
+
+	_, err := conn.Write(buf.Bytes())
+	return err
+}
+
+// readPacket reads and decodes one Source-RCON packet.
+func readPacket(conn net.Conn) (id, packetType int32, body string, err error) {
+	var size int32
+	if err := binary.Read(conn, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", err
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return 0, 0, "", err
+	}
+	if len(payload) < 8 {
+		return 0, 0, "", fmt.Errorf("packet too short")
+	}
+
+	id = int32(binary.LittleEndian.Uint32(payload[0:4]))
+	packetType = int32(binary.LittleEndian.Uint32(payload[4:8]))
+	body = string(bytes.TrimRight(payload[8:], "\x00"))
+	return id, packetType, body, nil
+}
@@ -0,0 +1,119 @@
+package rcon
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/yggdrion/squad-exporter/backend"
+)
+
+func TestParseServerInfo(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want backend.ServerState
+	}{
+		{
+			name: "valid",
+			body: `{"ServerName_s":"My Squad Server","MapName_s":"Narva","GameMode_s":"RAAS","TeamOne_s":"RGF","TeamTwo_s":"VDV"}`,
+			want: backend.ServerState{FullName: "My Squad Server", Map: "Narva", GameMode: "RAAS", SquadTeamOne: "RGF", SquadTeamTwo: "VDV"},
+		},
+		{
+			name: "invalid json returns zero value",
+			body: `not json`,
+			want: backend.ServerState{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseServerInfo(tc.body)
+			if got.FullName != tc.want.FullName || got.Map != tc.want.Map || got.GameMode != tc.want.GameMode ||
+				got.SquadTeamOne != tc.want.SquadTeamOne || got.SquadTeamTwo != tc.want.SquadTeamTwo {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCountPlayers(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{
+			name: "active players only",
+			body: "----- Active Players -----\nID: 0 | SteamID: 1 | Name: a\nID: 1 | SteamID: 2 | Name: b\n",
+			want: 2,
+		},
+		{
+			name: "stops counting at recently disconnected section",
+			body: "----- Active Players -----\n" +
+				"ID: 0 | SteamID: 1 | Name: a\n" +
+				"ID: 1 | SteamID: 2 | Name: b\n" +
+				"----- Recently Disconnected Players [Max of 15] -----\n" +
+				"ID: 0 | SteamID: 3 | Name: c\n" +
+				"ID: 1 | SteamID: 4 | Name: d\n",
+			want: 2,
+		},
+		{
+			name: "empty",
+			body: "----- Active Players -----\n----- Recently Disconnected Players [Max of 15] -----\n",
+			want: 0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := countPlayers(tc.body); got != tc.want {
+				t.Fatalf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSendCommandReassemblesMultiPacketResponse covers the fix from 9acb67a:
+// a response split across several packets sharing the command's id must be
+// reassembled up to the empty sentinel packet, not truncated at the first one.
+func TestSendCommandReassemblesMultiPacketResponse(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- func() error {
+			id, _, _, err := readPacket(server)
+			if err != nil {
+				return err
+			}
+			if id != packetIDCommand {
+				return fmt.Errorf("unexpected command packet id %d", id)
+			}
+			if _, _, _, err := readPacket(server); err != nil { // sentinel packet
+				return err
+			}
+
+			if err := writePacket(server, packetIDCommand, packetTypeExecCommand, "chunk one "); err != nil {
+				return err
+			}
+			if err := writePacket(server, packetIDCommand, packetTypeExecCommand, "chunk two"); err != nil {
+				return err
+			}
+			return writePacket(server, packetIDTerminator, packetTypeExecCommand, "")
+		}()
+	}()
+
+	got, err := sendCommand(client, "ListPlayers")
+	if err != nil {
+		t.Fatalf("sendCommand returned error: %v", err)
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatalf("fake server failed: %v", err)
+	}
+	if want := "chunk one chunk two"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
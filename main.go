@@ -3,42 +3,45 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"golang.org/x/time/rate"
+	"github.com/yggdrion/squad-exporter/backend"
+	"github.com/yggdrion/squad-exporter/backend/a2s"
+	"github.com/yggdrion/squad-exporter/backend/battlemetrics"
+	"github.com/yggdrion/squad-exporter/backend/rcon"
+	"github.com/yggdrion/squad-exporter/queuemanager"
 )
 
-// Server represents a server configuration
-type Server struct {
-	Name string `json:"Name"`
-	URL  string `json:"Url"`
-}
-
-// BattleMetricsResponse represents the API response structure (simplified)
-type BattleMetricsResponse struct {
-	Data struct {
-		Attributes struct {
-			Name    string `json:"name"`
-			Players int    `json:"players"`
-			Details struct {
-				Map           string `json:"map"`
-				GameMode      string `json:"gameMode"`
-				SquadPlayTime int    `json:"squad_playTime"`
-				SquadTeamOne  string `json:"squad_teamOne"`
-				SquadTeamTwo  string `json:"squad_teamTwo"`
-			} `json:"details"`
-		} `json:"attributes"`
-	} `json:"data"`
+// rateLimiterBurst caps how many BattleMetrics servers are fetched
+// concurrently. It matches the BattleMetrics burst capacity since that is
+// the only backend with a request quota to respect.
+const rateLimiterBurst = battlemetrics.BurstCapacity
+
+// Server represents a server configuration. Backend-specific fields are
+// defined in the backend package so every Backend implementation shares a
+// single, importable Server type.
+type Server = backend.Server
+
+// Config is the parsed form of servers.json. For backward compatibility the
+// file may also be a bare JSON array of servers, in which case RemoteWrite
+// is left empty.
+type Config struct {
+	Servers     []Server                      `json:"servers"`
+	RemoteWrite []queuemanager.EndpointConfig `json:"remote_write,omitempty"`
 }
 
 // Prometheus metrics
@@ -101,104 +104,91 @@ var (
 		},
 		[]string{"server_name"},
 	)
+
+	configReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "squad_config_reloads_total",
+			Help: "Total number of servers.json reload attempts",
+		},
+		[]string{"result"},
+	)
+
+	configLastReloadTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "squad_config_last_reload_timestamp_seconds",
+			Help: "Unix timestamp of the last successful servers.json reload",
+		},
+	)
 )
 
-// MetricsCollector handles collecting metrics from BattleMetrics API
+// MetricsCollector handles collecting metrics from the configured backends
 type MetricsCollector struct {
 	serversFile     string
-	httpClient      *http.Client
-	rateLimiter     *rate.Limiter
+	backends        map[backend.Type]backend.Backend
+	servers         atomic.Pointer[[]Server]
 	lastServerCount int
-	ticker          *time.Ticker
-	rateLimitHits   int
-	lastRateLimit   time.Time
+	// ticker is set by startMetricsCollection (called from main) but reset
+	// by reloadServers, which runs on the servers.json watcher goroutine -
+	// same cross-goroutine access pattern as servers above, so it needs the
+	// same atomic.Pointer treatment rather than a plain *time.Ticker field.
+	ticker          atomic.Pointer[time.Ticker]
+	stopLoop        chan struct{}
+	loopDone        chan struct{}
+	remoteWrite     *queuemanager.Manager
+	remoteWriteStop context.CancelFunc
 }
 
-// NewMetricsCollector creates a new metrics collector with rate limiting
+// NewMetricsCollector creates a new metrics collector with the default
+// backend registry (battlemetrics, a2s, rcon).
 func NewMetricsCollector(serversFile string) *MetricsCollector {
-	// BattleMetrics limits: 60 requests/minute (1/sec), 15 requests/second burst
-	// Optimize for maximum collection frequency while respecting limits
-	// - Burst: 15 requests (allows collecting all servers quickly)
-	// - Refill: 1 request/second (stays within 60/minute limit)
-	rateLimiter := rate.NewLimiter(rate.Every(time.Second), 15)
-
 	return &MetricsCollector{
-		serversFile:     serversFile,
-		httpClient:      &http.Client{Timeout: 10 * time.Second},
-		rateLimiter:     rateLimiter,
+		serversFile: serversFile,
+		backends: map[backend.Type]backend.Backend{
+			backend.TypeBattleMetrics: battlemetrics.New(),
+			backend.TypeA2S:           a2s.New(),
+			backend.TypeRCON:          rcon.New(),
+		},
 		lastServerCount: -1, // Initialize to -1 to force interval calculation on first run
 	}
 }
 
-// fetchServerData fetches data from BattleMetrics API for a single server
-func (mc *MetricsCollector) fetchServerData(server Server) error {
-	// Wait for rate limiter permission
-	ctx := context.Background()
-	if err := mc.rateLimiter.Wait(ctx); err != nil {
-		return fmt.Errorf("rate limiter error for server %s: %w", server.Name, err)
+// fetchServerData routes to the backend matching server.Type and updates
+// its metrics from the result.
+func (mc *MetricsCollector) fetchServerData(ctx context.Context, server Server) error {
+	be, ok := mc.backends[server.Type]
+	if !ok {
+		return &backend.UnsupportedTypeError{Type: server.Type}
 	}
 
-	resp, err := mc.httpClient.Get(server.URL)
+	state, err := be.FetchServer(ctx, server)
 	if err != nil {
 		scrapeErrors.WithLabelValues(server.Name).Inc()
-		return fmt.Errorf("failed to fetch data for server %s: %w", server.Name, err)
+		return err
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			log.Printf("Failed to close response body for server %s: %v", server.Name, closeErr)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		scrapeErrors.WithLabelValues(server.Name).Inc()
-
-		// Handle rate limiting specifically
-		if resp.StatusCode == 429 || resp.StatusCode == 503 {
-			// Read rate limit headers if available
-			retryAfter := resp.Header.Get("Retry-After")
-			rateLimitRemaining := resp.Header.Get("X-RateLimit-Remaining")
 
-			log.Printf("⚠️  Rate limit hit for server %s (HTTP %d). Retry-After: %s, Remaining: %s",
-				server.Name, resp.StatusCode, retryAfter, rateLimitRemaining)
-
-			// Return specific rate limit error
-			return fmt.Errorf("rate limit exceeded for server %s (HTTP %d), retry after: %s",
-				server.Name, resp.StatusCode, retryAfter)
-		}
-
-		return fmt.Errorf("unexpected status code %d for server %s", resp.StatusCode, server.Name)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		scrapeErrors.WithLabelValues(server.Name).Inc()
-		return fmt.Errorf("failed to read response body for server %s: %w", server.Name, err)
-	}
-
-	var bmResp BattleMetricsResponse
-	if err := json.Unmarshal(body, &bmResp); err != nil {
-		scrapeErrors.WithLabelValues(server.Name).Inc()
-		return fmt.Errorf("failed to unmarshal response for server %s: %w", server.Name, err)
-	}
-
-	// Update metrics
-	mc.updateMetrics(server.Name, bmResp)
+	mc.updateMetrics(server.Name, state)
 	return nil
 }
 
-// updateMetrics updates Prometheus metrics with server data
-func (mc *MetricsCollector) updateMetrics(serverName string, resp BattleMetricsResponse) {
-	attrs := resp.Data.Attributes
+// updateMetrics updates Prometheus metrics with a server's current state
+func (mc *MetricsCollector) updateMetrics(serverName string, state backend.ServerState) {
+	now := time.Now()
 
 	// Update main metrics with stable labels only
-	fridaSquadPlayerCount.WithLabelValues(serverName).Set(float64(attrs.Players))
-	fridaSquadPlayTime.WithLabelValues(serverName).Set(float64(attrs.Details.SquadPlayTime))
+	fridaSquadPlayerCount.WithLabelValues(serverName).Set(float64(state.Players))
+	fridaSquadPlayTime.WithLabelValues(serverName).Set(float64(state.SquadPlayTime))
+	mc.enqueueRemoteWrite("squad_player_count", map[string]string{"server_short_name": serverName}, float64(state.Players), now)
+	mc.enqueueRemoteWrite("squad_play_time_seconds", map[string]string{"server_short_name": serverName}, float64(state.SquadPlayTime), now)
 
 	// Update static server info (value is always 1)
 	fridaSquadServerInfo.WithLabelValues(
-		serverName, // server_short_name
-		attrs.Name, // server_full_name
+		serverName,     // server_short_name
+		state.FullName, // server_full_name
 	).Set(1)
+	mc.enqueueRemoteWrite("squad_server_info", map[string]string{
+		"server_short_name": serverName,
+		"server_full_name":  state.FullName,
+	}, 1, now)
 
 	// Clear previous dynamic state metrics for this server to prevent stale data
 	fridaSquadCurrentMap.DeletePartialMatch(prometheus.Labels{"server_short_name": serverName})
@@ -207,127 +197,388 @@ func (mc *MetricsCollector) updateMetrics(serverName string, resp BattleMetricsR
 
 	// Update dynamic game state metrics
 	fridaSquadCurrentMap.WithLabelValues(
-		serverName,        // server_short_name
-		attrs.Details.Map, // map_name
+		serverName, // server_short_name
+		state.Map,  // map_name
 	).Set(1)
+	mc.enqueueRemoteWrite("squad_current_map", map[string]string{
+		"server_short_name": serverName,
+		"map_name":          state.Map,
+	}, 1, now)
 
 	fridaSquadCurrentGameMode.WithLabelValues(
-		serverName,             // server_short_name
-		attrs.Details.GameMode, // game_mode
+		serverName,     // server_short_name
+		state.GameMode, // game_mode
 	).Set(1)
+	mc.enqueueRemoteWrite("squad_current_game_mode", map[string]string{
+		"server_short_name": serverName,
+		"game_mode":         state.GameMode,
+	}, 1, now)
 
 	fridaSquadCurrentTeams.WithLabelValues(
-		serverName,                 // server_short_name
-		attrs.Details.SquadTeamOne, // team_one
-		attrs.Details.SquadTeamTwo, // team_two
+		serverName,         // server_short_name
+		state.SquadTeamOne, // team_one
+		state.SquadTeamTwo, // team_two
 	).Set(1)
+	mc.enqueueRemoteWrite("squad_current_teams", map[string]string{
+		"server_short_name": serverName,
+		"team_one":          state.SquadTeamOne,
+		"team_two":          state.SquadTeamTwo,
+	}, 1, now)
 }
 
-// collectMetrics fetches data for all servers with rate limiting
-func (mc *MetricsCollector) collectMetrics() {
-	startTime := time.Now()
+// enqueueRemoteWrite feeds a sample to the remote-write queue, if one is
+// configured. It is a no-op otherwise so remote-write stays fully optional.
+func (mc *MetricsCollector) enqueueRemoteWrite(metricName string, labels map[string]string, value float64, ts time.Time) {
+	if mc.remoteWrite == nil {
+		return
+	}
+	labels["__name__"] = metricName
+	mc.remoteWrite.Enqueue(queuemanager.Sample{Labels: labels, Value: value, Timestamp: ts})
+}
 
-	// Reload servers from file before each collection
+// currentServers returns the server list currently in effect. It is kept
+// up to date by the servers.json watcher rather than re-read on every tick.
+func (mc *MetricsCollector) currentServers() []Server {
+	p := mc.servers.Load()
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// watchServersFile loads the initial server list and then watches
+// serversFile for changes, reloading on WRITE/CREATE/RENAME events instead
+// of re-reading the file on every collection tick.
+func (mc *MetricsCollector) watchServersFile() error {
 	servers, err := loadServers(mc.serversFile)
 	if err != nil {
+		return err
+	}
+	mc.servers.Store(&servers)
+	mc.lastServerCount = len(servers)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher for %s: %w", mc.serversFile, err)
+	}
+
+	if err := watcher.Add(mc.serversFile); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", mc.serversFile, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				// Editors like vim save by renaming the old file away and
+				// creating a new one in its place, which drops the
+				// underlying watch. Re-add it after every event so we keep
+				// watching the file that now exists at this path.
+				if err := watcher.Add(mc.serversFile); err != nil {
+					log.Printf("Failed to re-watch %s after %s: %v", mc.serversFile, event.Op, err)
+				}
+
+				mc.reloadServers()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("servers.json watcher error: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reloadServers re-reads serversFile, atomically swaps it in, adjusts the
+// collection interval if the server count changed, and deletes stale
+// per-server label sets for servers that disappeared from the file.
+func (mc *MetricsCollector) reloadServers() {
+	newServers, err := loadServers(mc.serversFile)
+	if err != nil {
+		configReloadsTotal.WithLabelValues("failure").Inc()
 		log.Printf("Failed to reload servers from %s: %v", mc.serversFile, err)
 		return
 	}
 
-	// Check if server count changed and adjust interval if needed
-	if len(servers) != mc.lastServerCount {
-		newInterval := calculateOptimalInterval(len(servers))
+	oldServers := mc.currentServers()
+	mc.servers.Store(&newServers)
+	removeStaleServerMetrics(oldServers, newServers)
+
+	configReloadsTotal.WithLabelValues("success").Inc()
+	configLastReloadTimestamp.SetToCurrentTime()
+
+	if len(newServers) != mc.lastServerCount {
+		newInterval := calculateOptimalInterval(len(newServers))
 		log.Printf("Server count changed: %d → %d, adjusting interval to %v",
-			mc.lastServerCount, len(servers), newInterval)
+			mc.lastServerCount, len(newServers), newInterval)
 
-		// Reset ticker with new interval
-		if mc.ticker != nil {
-			mc.ticker.Reset(newInterval)
+		if t := mc.ticker.Load(); t != nil {
+			t.Reset(newInterval)
 		}
-		mc.lastServerCount = len(servers)
+		mc.lastServerCount = len(newServers)
 	}
 
-	log.Printf("Starting metrics collection for %d servers (reloaded from %s)", len(servers), mc.serversFile)
+	log.Printf("Reloaded %d servers from %s", len(newServers), mc.serversFile)
+}
 
-	// Check if we recently hit rate limits and should back off
-	if time.Since(mc.lastRateLimit) < 30*time.Second && mc.rateLimitHits > 0 {
-		log.Printf("🚨 Recently hit rate limits (%d times), backing off for 30s", mc.rateLimitHits)
-		return
+// removeStaleServerMetrics deletes label sets for servers present in
+// oldServers but absent from newServers, so Prometheus stops seeing zombie
+// series for servers that were removed from servers.json.
+func removeStaleServerMetrics(oldServers, newServers []Server) {
+	stillPresent := make(map[string]bool, len(newServers))
+	for _, s := range newServers {
+		stillPresent[s.Name] = true
+	}
+
+	for _, s := range oldServers {
+		if stillPresent[s.Name] {
+			continue
+		}
+
+		labels := prometheus.Labels{"server_short_name": s.Name}
+		fridaSquadPlayerCount.DeletePartialMatch(labels)
+		fridaSquadPlayTime.DeletePartialMatch(labels)
+		fridaSquadServerInfo.DeletePartialMatch(labels)
+		fridaSquadCurrentMap.DeletePartialMatch(labels)
+		fridaSquadCurrentGameMode.DeletePartialMatch(labels)
+		fridaSquadCurrentTeams.DeletePartialMatch(labels)
+		log.Printf("Removed stale metrics for server %s (no longer in servers.json)", s.Name)
 	}
+}
 
-	// Process servers sequentially to respect rate limits
+// collectMetrics fetches data for all servers with rate limiting
+func (mc *MetricsCollector) collectMetrics() {
+	mc.collectMetricsContext(context.Background())
+}
+
+// collectMetricsContext is the same as collectMetrics but runs under a
+// caller-supplied context, so the final shutdown scrape can be bounded by a
+// deadline instead of running unbounded.
+func (mc *MetricsCollector) collectMetricsContext(parent context.Context) {
+	startTime := time.Now()
+
+	servers := mc.currentServers()
+	log.Printf("Starting metrics collection for %d servers", len(servers))
+
+	// Fetch servers concurrently instead of one at a time. Each backend
+	// type gets its own concurrency semaphore: BattleMetrics is capped at
+	// its burst capacity since every BattleMetrics server shares one rate
+	// limiter, while a2s/rcon servers are queried directly with no shared
+	// quota, so capping them at the BattleMetrics burst would leave a
+	// self-hosted fleet of more than rateLimiterBurst servers artificially
+	// serialized.
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	sems := semaphoresByType(servers)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
 	successCount := 0
-	rateLimitCount := 0
+	errorCount := 0
+
 	for _, server := range servers {
-		if err := mc.fetchServerData(server); err != nil {
-			// Check if this was a rate limit error
-			if strings.Contains(err.Error(), "rate limit exceeded") {
-				rateLimitCount++
-				mc.rateLimitHits++
-				mc.lastRateLimit = time.Now()
-
-				log.Printf("🚨 Rate limit detected! Stopping collection early to prevent further violations")
-				break // Stop processing more servers
+		if ctx.Err() != nil {
+			break // Parent context (e.g. the shutdown deadline) expired.
+		}
+
+		sem := sems[server.Type]
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(server Server) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := mc.fetchServerData(ctx, server)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errorCount++
+				log.Printf("Error fetching data for server %s: %v", server.Name, err)
+				return
 			}
-			log.Printf("Error fetching data for server %s: %v", server.Name, err)
-		} else {
 			successCount++
-			// Reset rate limit counter on successful request
-			if mc.rateLimitHits > 0 {
-				mc.rateLimitHits = 0
-				log.Printf("✅ Rate limit counter reset after successful request")
-			}
-		}
+		}(server)
 	}
+	wg.Wait()
 
 	duration := time.Since(startTime)
-	if rateLimitCount > 0 {
-		log.Printf("⚠️  Collection completed: %d/%d servers successful, %d rate limits hit, in %v",
-			successCount, len(servers), rateLimitCount, duration)
+	if errorCount > 0 {
+		log.Printf("⚠️  Collection completed: %d/%d servers successful, %d errors, in %v",
+			successCount, len(servers), errorCount, duration)
 	} else {
 		log.Printf("✅ Collection completed: %d/%d servers successful in %v", successCount, len(servers), duration)
 	}
 }
 
-// startMetricsCollection starts a goroutine that periodically collects metrics
-func (mc *MetricsCollector) startMetricsCollection(interval time.Duration) {
-	mc.ticker = time.NewTicker(interval)
+// semaphoresByType returns one concurrency semaphore per backend type
+// present in servers. BattleMetrics is capped at rateLimiterBurst since
+// every BattleMetrics server shares a single rate-limited quota; backends
+// with no shared quota (a2s, rcon) get one slot per server instead, so they
+// aren't serialized behind an unrelated backend's limit.
+func semaphoresByType(servers []Server) map[backend.Type]chan struct{} {
+	counts := make(map[backend.Type]int, len(servers))
+	for _, s := range servers {
+		counts[s.Type]++
+	}
+
+	sems := make(map[backend.Type]chan struct{}, len(counts))
+	for t, n := range counts {
+		capacity := n
+		if t == backend.TypeBattleMetrics && capacity > rateLimiterBurst {
+			capacity = rateLimiterBurst
+		}
+		sems[t] = make(chan struct{}, capacity)
+	}
+	return sems
+}
+
+// startMetricsCollection starts a goroutine that periodically collects
+// metrics. If scrapeOnStartup is false, the first collection waits for the
+// ticker instead of firing immediately. The loop exits as soon as stopLoop
+// is closed, even mid-wait on the ticker, so shutdown can wait for it to
+// fully stop instead of racing its own final collection against it.
+func (mc *MetricsCollector) startMetricsCollection(interval time.Duration, scrapeOnStartup bool) {
+	t := time.NewTicker(interval)
+	mc.ticker.Store(t)
+	mc.stopLoop = make(chan struct{})
+	mc.loopDone = make(chan struct{})
+
 	go func() {
-		// Collect metrics immediately on startup
-		mc.collectMetrics()
+		defer close(mc.loopDone)
 
-		for range mc.ticker.C {
+		if scrapeOnStartup {
 			mc.collectMetrics()
 		}
+
+		for {
+			select {
+			case <-mc.stopLoop:
+				return
+			case <-t.C:
+				mc.collectMetrics()
+			}
+		}
 	}()
 }
 
+// shutdown stops the ticker and waits for the periodic collection loop to
+// fully exit - including any collection already in flight - so it can't
+// race the final scrape below over the same servers and rate-limiter
+// budget. It then optionally runs one final bounded collection so
+// short-lived deployments get at least two data points, and drains any
+// configured remote-write queue before returning. It respects ctx's deadline
+// throughout so shutdown cannot hang forever.
+func (mc *MetricsCollector) shutdown(ctx context.Context, scrapeOnShutdown bool) {
+	if t := mc.ticker.Load(); t != nil {
+		t.Stop()
+	}
+	if mc.stopLoop != nil {
+		close(mc.stopLoop)
+	}
+	if mc.loopDone != nil {
+		select {
+		case <-mc.loopDone:
+		case <-ctx.Done():
+			log.Printf("Timed out waiting for in-flight metrics collection to finish")
+		}
+	}
+
+	if scrapeOnShutdown {
+		log.Printf("Performing final metrics collection before shutdown")
+		mc.collectMetricsContext(ctx)
+	}
+
+	if mc.remoteWrite != nil {
+		log.Printf("Draining remote-write queue before shutdown")
+		mc.remoteWriteStop()
+
+		drained := make(chan struct{})
+		go func() {
+			mc.remoteWrite.Wait()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			log.Printf("Timed out waiting for remote-write queue to drain")
+		}
+	}
+}
+
 func loadServers(filename string) ([]Server, error) {
+	cfg, err := loadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Servers, nil
+}
+
+// loadConfig reads and parses servers.json. It accepts either the legacy
+// bare JSON array of servers, or an object with a "servers" key and an
+// optional "remote_write" section.
+func loadConfig(filename string) (Config, error) {
 	// Check if the file exists and is actually a file (not a directory)
 	fileInfo, err := os.Stat(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("servers file '%s' does not exist - please create it", filename)
+			return Config{}, fmt.Errorf("servers file '%s' does not exist - please create it", filename)
 		}
-		return nil, fmt.Errorf("failed to stat servers file '%s': %w", filename, err)
+		return Config{}, fmt.Errorf("failed to stat servers file '%s': %w", filename, err)
 	}
 
 	// Ensure it's actually a file, not a directory
 	if fileInfo.IsDir() {
-		return nil, fmt.Errorf("'%s' is a directory, not a file - please remove the directory and create a proper JSON file", filename)
+		return Config{}, fmt.Errorf("'%s' is a directory, not a file - please remove the directory and create a proper JSON file", filename)
 	}
 
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read servers file '%s': %w", filename, err)
+		return Config{}, fmt.Errorf("failed to read servers file '%s': %w", filename, err)
 	}
 
+	// A bare JSON array (the legacy format, handled below) never unmarshals
+	// successfully into Config, so success here reliably means the object
+	// form was used - even with an empty or omitted "servers" key, which
+	// must be accepted for remote-write-only deployments.
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err == nil {
+		applyDefaultBackendType(cfg.Servers)
+		return cfg, nil
+	}
+
+	// Fall back to the legacy format: a bare JSON array of servers.
 	var servers []Server
 	if err := json.Unmarshal(data, &servers); err != nil {
-		return nil, fmt.Errorf("failed to decode servers JSON from '%s': %w", filename, err)
+		return Config{}, fmt.Errorf("failed to decode servers JSON from '%s': %w", filename, err)
 	}
+	applyDefaultBackendType(servers)
+
+	return Config{Servers: servers}, nil
+}
 
-	return servers, nil
+// applyDefaultBackendType defaults a server's backend to battlemetrics when
+// "type" is omitted, preserving backward compatibility with servers.json
+// files that predate the pluggable backend support.
+func applyDefaultBackendType(servers []Server) {
+	for i := range servers {
+		if servers[i].Type == "" {
+			servers[i].Type = backend.TypeBattleMetrics
+		}
+	}
 }
 
 // calculateOptimalInterval calculates the best collection interval based on server count
@@ -359,19 +610,25 @@ func calculateOptimalInterval(serverCount int) time.Duration {
 }
 
 func main() {
+	scrapeOnStartup := flag.Bool("scrape-on-startup", true, "Collect metrics immediately on startup instead of waiting for the first tick")
+	scrapeOnShutdown := flag.Bool("scrape-on-shutdown", true, "Collect metrics one final time before shutting down")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "Maximum time to wait for the final scrape and remote-write drain on shutdown")
+	flag.Parse()
+
 	// Load server configurations initially to verify file format
-	servers, err := loadServers("servers.json")
+	cfg, err := loadConfig("servers.json")
 	if err != nil {
 		log.Fatalf("FATAL: Cannot start without valid servers.json file: %v", err)
 	}
+	servers := cfg.Servers
 
 	// Calculate optimal collection interval based on server count
 	interval := calculateOptimalInterval(len(servers))
 
 	log.Printf("Initial load: %d servers", len(servers))
-	log.Printf("Rate limiting: 15 req/sec burst, 1 req/sec refill")
+	log.Printf("BattleMetrics rate limiting: %d req/sec burst, 1 req/sec refill", battlemetrics.BurstCapacity)
 	log.Printf("Optimal collection interval: %v (based on %d servers)", interval, len(servers))
-	log.Printf("Servers will be reloaded before each collection - interval may adjust dynamically")
+	log.Printf("servers.json will be watched for changes and hot-reloaded")
 
 	// Register Prometheus metrics
 	prometheus.MustRegister(
@@ -382,13 +639,29 @@ func main() {
 		fridaSquadCurrentGameMode,
 		fridaSquadCurrentTeams,
 		scrapeErrors,
+		configReloadsTotal,
+		configLastReloadTimestamp,
 	)
 
 	// Create metrics collector with filename
 	collector := NewMetricsCollector("servers.json")
 
+	// Wire up remote-write shipping if the config declares any endpoints
+	if len(cfg.RemoteWrite) > 0 {
+		log.Printf("Remote-write enabled: shipping to %d endpoint(s)", len(cfg.RemoteWrite))
+		remoteWriteCtx, stopRemoteWrite := context.WithCancel(context.Background())
+		collector.remoteWrite = queuemanager.NewManager(cfg.RemoteWrite)
+		collector.remoteWrite.Start(remoteWriteCtx)
+		collector.remoteWriteStop = stopRemoteWrite
+	}
+
+	// Load the initial server list and start watching servers.json for changes
+	if err := collector.watchServersFile(); err != nil {
+		log.Fatalf("FATAL: Cannot watch servers.json: %v", err)
+	}
+
 	// Start collecting metrics with dynamic interval
-	collector.startMetricsCollection(interval)
+	collector.startMetricsCollection(interval, *scrapeOnStartup)
 
 	// Setup Chi router
 	r := chi.NewRouter()
@@ -397,17 +670,10 @@ func main() {
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		// Load current server count
-		currentServers, err := loadServers("servers.json")
-		serverCount := 0
-		if err == nil {
-			serverCount = len(currentServers)
-		}
-
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
 			"service":   "Squad Server Metrics",
-			"servers":   serverCount,
-			"note":      "Servers are reloaded from servers.json before each collection",
+			"servers":   len(collector.currentServers()),
+			"note":      "servers.json is watched and hot-reloaded on change",
 			"endpoints": []string{"/metrics", "/health"},
 		}); err != nil {
 			log.Printf("Failed to encode JSON response: %v", err)
@@ -420,6 +686,30 @@ func main() {
 		port = p
 	}
 
-	log.Printf("Starting server on http://localhost:%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Starting server on http://localhost:%s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("HTTP server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutdown signal received, shutting down gracefully (timeout %v)", *shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	collector.shutdown(shutdownCtx, *scrapeOnShutdown)
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+
+	log.Printf("Shutdown complete")
 }
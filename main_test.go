@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/yggdrion/squad-exporter/backend"
+)
+
+func writeTempConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "servers.json")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigObjectForm(t *testing.T) {
+	path := writeTempConfig(t, `{"servers":[{"Name":"a","Url":"http://example.com"}],"remote_write":[{"name":"x","url":"http://rw.example.com"}]}`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if len(cfg.Servers) != 1 || cfg.Servers[0].Name != "a" {
+		t.Fatalf("got servers %+v, want one server named 'a'", cfg.Servers)
+	}
+	if len(cfg.RemoteWrite) != 1 || cfg.RemoteWrite[0].Name != "x" {
+		t.Fatalf("got remote_write %+v, want one endpoint named 'x'", cfg.RemoteWrite)
+	}
+}
+
+// TestLoadConfigEmptyServersWithRemoteWrite is a regression test for a bug
+// where an object-form config with zero servers was rejected because
+// loadConfig's success condition required len(cfg.Servers) > 0, breaking
+// remote-write-only / scaled-to-zero deployments.
+func TestLoadConfigEmptyServersWithRemoteWrite(t *testing.T) {
+	path := writeTempConfig(t, `{"servers":[],"remote_write":[{"name":"x","url":"http://rw.example.com"}]}`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if len(cfg.Servers) != 0 {
+		t.Fatalf("got %d servers, want 0", len(cfg.Servers))
+	}
+	if len(cfg.RemoteWrite) != 1 || cfg.RemoteWrite[0].Name != "x" {
+		t.Fatalf("got remote_write %+v, want one endpoint named 'x'", cfg.RemoteWrite)
+	}
+}
+
+func TestLoadConfigOmittedServersKey(t *testing.T) {
+	path := writeTempConfig(t, `{"remote_write":[{"name":"x","url":"http://rw.example.com"}]}`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if len(cfg.Servers) != 0 {
+		t.Fatalf("got %d servers, want 0", len(cfg.Servers))
+	}
+}
+
+func TestLoadConfigLegacyArrayForm(t *testing.T) {
+	path := writeTempConfig(t, `[{"Name":"a","Url":"http://example.com"},{"Name":"b","Url":"http://example.com"}]`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if len(cfg.Servers) != 2 {
+		t.Fatalf("got %d servers, want 2", len(cfg.Servers))
+	}
+	if len(cfg.RemoteWrite) != 0 {
+		t.Fatalf("got remote_write %+v, want none for the legacy array form", cfg.RemoteWrite)
+	}
+	for _, s := range cfg.Servers {
+		if s.Type != backend.TypeBattleMetrics {
+			t.Fatalf("got type %q, want default %q to be applied", s.Type, backend.TypeBattleMetrics)
+		}
+	}
+}
+
+func TestLoadConfigInvalidJSON(t *testing.T) {
+	path := writeTempConfig(t, `not json at all`)
+
+	if _, err := loadConfig(path); err == nil {
+		t.Fatal("expected an error for invalid JSON, got nil")
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "does-not-exist.json")); err == nil {
+		t.Fatal("expected an error for a missing file, got nil")
+	}
+}
+
+func TestRemoveStaleServerMetrics(t *testing.T) {
+	fridaSquadPlayerCount.Reset()
+	defer fridaSquadPlayerCount.Reset()
+
+	fridaSquadPlayerCount.WithLabelValues("alpha").Set(5)
+	fridaSquadPlayerCount.WithLabelValues("beta").Set(3)
+
+	removeStaleServerMetrics(
+		[]Server{{Name: "alpha"}, {Name: "beta"}},
+		[]Server{{Name: "alpha"}},
+	)
+
+	if got := testutil.CollectAndCount(fridaSquadPlayerCount); got != 1 {
+		t.Fatalf("got %d player_count series after removing stale metrics, want 1", got)
+	}
+	if got := testutil.ToFloat64(fridaSquadPlayerCount.WithLabelValues("alpha")); got != 5 {
+		t.Fatalf("alpha's player count changed unexpectedly: got %v", got)
+	}
+}
+
+func TestRemoveStaleServerMetricsKeepsEverythingWhenNothingDisappeared(t *testing.T) {
+	fridaSquadPlayerCount.Reset()
+	defer fridaSquadPlayerCount.Reset()
+
+	fridaSquadPlayerCount.WithLabelValues("alpha").Set(5)
+
+	removeStaleServerMetrics(
+		[]Server{{Name: "alpha"}},
+		[]Server{{Name: "alpha"}},
+	)
+
+	if got := testutil.CollectAndCount(fridaSquadPlayerCount); got != 1 {
+		t.Fatalf("got %d player_count series, want the unchanged server's series kept", got)
+	}
+}
+
+// TestReloadServersSwapsServersAdjustsCountAndClearsStaleMetrics covers
+// reloadServers: the in-effect server list is swapped, lastServerCount is
+// updated to the new server count, and metrics for servers that dropped out
+// of the file are cleared.
+func TestReloadServersSwapsServersAdjustsCountAndClearsStaleMetrics(t *testing.T) {
+	fridaSquadPlayerCount.Reset()
+	defer fridaSquadPlayerCount.Reset()
+
+	path := writeTempConfig(t, `[{"Name":"alpha","Url":"http://example.com"}]`)
+	mc := NewMetricsCollector(path)
+
+	initial := []Server{
+		{Name: "alpha", Type: backend.TypeBattleMetrics},
+		{Name: "beta", Type: backend.TypeBattleMetrics},
+	}
+	mc.servers.Store(&initial)
+	mc.lastServerCount = len(initial)
+	fridaSquadPlayerCount.WithLabelValues("beta").Set(7)
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	mc.ticker.Store(ticker)
+
+	mc.reloadServers()
+
+	if got := mc.currentServers(); len(got) != 1 || got[0].Name != "alpha" {
+		t.Fatalf("got servers %+v after reload, want only 'alpha'", got)
+	}
+	if mc.lastServerCount != 1 {
+		t.Fatalf("lastServerCount = %d, want 1", mc.lastServerCount)
+	}
+	if got := testutil.CollectAndCount(fridaSquadPlayerCount); got != 0 {
+		t.Fatalf("got %d player_count series after reload, want the stale 'beta' series removed", got)
+	}
+}
+
+func TestApplyDefaultBackendType(t *testing.T) {
+	servers := []Server{
+		{Name: "a"},
+		{Name: "b", Type: backend.TypeRCON},
+	}
+
+	applyDefaultBackendType(servers)
+
+	if servers[0].Type != backend.TypeBattleMetrics {
+		t.Fatalf("got type %q for server with no type set, want default %q", servers[0].Type, backend.TypeBattleMetrics)
+	}
+	if servers[1].Type != backend.TypeRCON {
+		t.Fatalf("got type %q for server with an explicit type, want it left unchanged as %q", servers[1].Type, backend.TypeRCON)
+	}
+}
+
+func TestSemaphoresByType(t *testing.T) {
+	servers := make([]Server, 0, rateLimiterBurst+5)
+	for i := 0; i < rateLimiterBurst+5; i++ {
+		servers = append(servers, Server{Type: backend.TypeBattleMetrics})
+	}
+	for i := 0; i < 2; i++ {
+		servers = append(servers, Server{Type: backend.TypeA2S})
+	}
+
+	sems := semaphoresByType(servers)
+
+	if got, want := cap(sems[backend.TypeBattleMetrics]), rateLimiterBurst; got != want {
+		t.Fatalf("battlemetrics semaphore capacity = %d, want it capped at %d", got, want)
+	}
+	if got, want := cap(sems[backend.TypeA2S]), 2; got != want {
+		t.Fatalf("a2s semaphore capacity = %d, want one slot per server (%d)", got, want)
+	}
+	if _, ok := sems[backend.TypeRCON]; ok {
+		t.Fatal("got a semaphore for a backend type with no servers configured")
+	}
+}
+
+func TestSemaphoresByTypeBattleMetricsUnderBurstLimit(t *testing.T) {
+	servers := []Server{{Type: backend.TypeBattleMetrics}, {Type: backend.TypeBattleMetrics}}
+
+	sems := semaphoresByType(servers)
+
+	if got, want := cap(sems[backend.TypeBattleMetrics]), 2; got != want {
+		t.Fatalf("battlemetrics semaphore capacity = %d, want %d (no cap needed below the burst limit)", got, want)
+	}
+}
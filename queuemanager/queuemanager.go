@@ -0,0 +1,317 @@
+// Package queuemanager batches, protobuf-encodes and ships Prometheus
+// samples to one or more remote-write endpoints. Each configured endpoint
+// gets its own shard: a buffered queue plus a goroutine that flushes on a
+// batch-size or time deadline, retrying failed sends with exponential
+// backoff.
+package queuemanager
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// Sample is a single labeled value queued for remote-write delivery.
+type Sample struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// EndpointConfig describes a single remote-write destination, as parsed
+// from the `remote_write` section of servers.json.
+type EndpointConfig struct {
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	BearerToken     string            `json:"bearer_token,omitempty"`
+	BasicAuthUser   string            `json:"basic_auth_user,omitempty"`
+	BasicAuthPass   string            `json:"basic_auth_password,omitempty"`
+	ExternalLabels  map[string]string `json:"external_labels,omitempty"`
+	BatchSize       int               `json:"batch_size,omitempty"`
+	FlushIntervalMs int               `json:"flush_interval_ms,omitempty"`
+}
+
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = 5 * time.Second
+	maxSendAttempts      = 5
+	initialBackoff       = time.Second
+	drainFlushTimeout    = 15 * time.Second
+)
+
+var (
+	samplesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "squad_remote_write_samples_total",
+			Help: "Total number of samples successfully shipped to a remote-write endpoint",
+		},
+		[]string{"endpoint"},
+	)
+
+	failedSamplesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "squad_remote_write_failed_samples_total",
+			Help: "Total number of samples dropped after exhausting retries or because the shard queue was full",
+		},
+		[]string{"endpoint"},
+	)
+
+	shardQueueLength = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "squad_remote_write_shard_queue_length",
+			Help: "Current number of samples buffered in a shard's queue",
+		},
+		[]string{"endpoint"},
+	)
+
+	sentBatchDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "squad_remote_write_sent_batch_duration_seconds",
+			Help:    "Time taken to successfully send a batch to a remote-write endpoint",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"endpoint"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(samplesTotal, failedSamplesTotal, shardQueueLength, sentBatchDuration)
+}
+
+// shard owns delivery for a single remote-write endpoint.
+type shard struct {
+	cfg           EndpointConfig
+	queue         chan Sample
+	flushInterval time.Duration
+	client        *http.Client
+}
+
+// Manager fans samples out to every configured remote-write endpoint.
+type Manager struct {
+	shards []*shard
+	wg     sync.WaitGroup
+}
+
+// NewManager builds a Manager with one shard per endpoint. Endpoints with
+// no batch size or flush interval configured fall back to sane defaults.
+func NewManager(endpoints []EndpointConfig) *Manager {
+	m := &Manager{}
+	for _, cfg := range endpoints {
+		if cfg.BatchSize <= 0 {
+			cfg.BatchSize = defaultBatchSize
+		}
+		flushInterval := defaultFlushInterval
+		if cfg.FlushIntervalMs > 0 {
+			flushInterval = time.Duration(cfg.FlushIntervalMs) * time.Millisecond
+		}
+		m.shards = append(m.shards, &shard{
+			cfg:           cfg,
+			queue:         make(chan Sample, 10000),
+			flushInterval: flushInterval,
+			client:        &http.Client{Timeout: 30 * time.Second},
+		})
+	}
+	return m
+}
+
+// Start launches one goroutine per shard. Each goroutine runs until ctx is
+// cancelled, at which point it flushes whatever batch it was building.
+func (m *Manager) Start(ctx context.Context) {
+	for _, s := range m.shards {
+		m.wg.Add(1)
+		go func(s *shard) {
+			defer m.wg.Done()
+			s.run(ctx)
+		}(s)
+	}
+}
+
+// Wait blocks until every shard goroutine has returned. Used to drain the
+// queues on shutdown.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Enqueue fans a sample out to every configured endpoint. A shard whose
+// queue is full drops the sample rather than blocking the caller.
+func (m *Manager) Enqueue(s Sample) {
+	for _, sh := range m.shards {
+		select {
+		case sh.queue <- s:
+			shardQueueLength.WithLabelValues(sh.cfg.Name).Set(float64(len(sh.queue)))
+		default:
+			failedSamplesTotal.WithLabelValues(sh.cfg.Name).Inc()
+			log.Printf("queuemanager: queue full for endpoint %s, dropping sample", sh.cfg.Name)
+		}
+	}
+}
+
+func (sh *shard) run(ctx context.Context) {
+	ticker := time.NewTicker(sh.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Sample, 0, sh.cfg.BatchSize)
+	flush := func(sendCtx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		sh.send(sendCtx, batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx.Done() and a pending sample in sh.queue can both be
+			// ready at once, in which case select may pick Done() first
+			// and leave that sample unread. Drain whatever's left in the
+			// queue before flushing so shutdown never silently drops it.
+			drainRemainingQueue(sh.queue, &batch)
+
+			// ctx just fired Done, so sending the final batch with it
+			// would fail immediately with "context canceled" and never
+			// reach the network. Give the drain its own bounded context
+			// instead so shutdown can still deliver it.
+			drainCtx, cancel := context.WithTimeout(context.Background(), drainFlushTimeout)
+			flush(drainCtx)
+			cancel()
+			return
+		case sample := <-sh.queue:
+			batch = append(batch, sample)
+			shardQueueLength.WithLabelValues(sh.cfg.Name).Set(float64(len(sh.queue)))
+			if len(batch) >= sh.cfg.BatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
+
+// drainRemainingQueue appends any samples already sitting in queue to
+// *batch without blocking, so samples enqueued right before shutdown aren't
+// dropped.
+func drainRemainingQueue(queue chan Sample, batch *[]Sample) {
+	for {
+		select {
+		case sample := <-queue:
+			*batch = append(*batch, sample)
+		default:
+			return
+		}
+	}
+}
+
+// send protobuf-encodes and snappy-compresses batch per the Prometheus
+// remote-write spec, then POSTs it with retries and exponential backoff on
+// 5xx/429, honoring Retry-After when the server sends one.
+func (sh *shard) send(ctx context.Context, batch []Sample) {
+	start := time.Now()
+
+	req := &prompb.WriteRequest{Timeseries: make([]prompb.TimeSeries, 0, len(batch))}
+	for _, s := range batch {
+		labels := make([]prompb.Label, 0, len(s.Labels)+len(sh.cfg.ExternalLabels))
+		for k, v := range s.Labels {
+			labels = append(labels, prompb.Label{Name: k, Value: v})
+		}
+		for k, v := range sh.cfg.ExternalLabels {
+			labels = append(labels, prompb.Label{Name: k, Value: v})
+		}
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Value: s.Value, Timestamp: s.Timestamp.UnixMilli()}},
+		})
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		log.Printf("queuemanager: failed to marshal batch for endpoint %s: %v", sh.cfg.Name, err)
+		failedSamplesTotal.WithLabelValues(sh.cfg.Name).Add(float64(len(batch)))
+		return
+	}
+	compressed := snappy.Encode(nil, data)
+
+	backoff := initialBackoff
+	attempts := 0
+	for attempt := 0; attempt <= maxSendAttempts; attempt++ {
+		attempts++
+		ok, retryable, retryAfter := sh.post(ctx, compressed)
+		if ok {
+			samplesTotal.WithLabelValues(sh.cfg.Name).Add(float64(len(batch)))
+			sentBatchDuration.WithLabelValues(sh.cfg.Name).Observe(time.Since(start).Seconds())
+			return
+		}
+		if !retryable || attempt == maxSendAttempts {
+			break
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		select {
+		case <-ctx.Done():
+			failedSamplesTotal.WithLabelValues(sh.cfg.Name).Add(float64(len(batch)))
+			return
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+
+	failedSamplesTotal.WithLabelValues(sh.cfg.Name).Add(float64(len(batch)))
+	log.Printf("queuemanager: giving up on batch of %d samples for endpoint %s after %d attempts",
+		len(batch), sh.cfg.Name, attempts)
+}
+
+// post sends a single compressed batch and reports whether it succeeded,
+// whether a failure is worth retrying (transport errors, 5xx and 429) and,
+// if the server asked for a delay, how long to wait before retrying.
+func (sh *shard) post(ctx context.Context, body []byte) (ok, retryable bool, retryAfter time.Duration) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sh.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("queuemanager: failed to build request for endpoint %s: %v", sh.cfg.Name, err)
+		return false, false, 0
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	switch {
+	case sh.cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+sh.cfg.BearerToken)
+	case sh.cfg.BasicAuthUser != "":
+		req.SetBasicAuth(sh.cfg.BasicAuthUser, sh.cfg.BasicAuthPass)
+	}
+
+	resp, err := sh.client.Do(req)
+	if err != nil {
+		log.Printf("queuemanager: request failed for endpoint %s: %v", sh.cfg.Name, err)
+		return false, true, 0
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return true, false, 0
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5:
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			if secs, err := strconv.Atoi(ra); err == nil {
+				retryAfter = time.Duration(secs) * time.Second
+			}
+		}
+		log.Printf("queuemanager: endpoint %s returned %d, will retry", sh.cfg.Name, resp.StatusCode)
+		return false, true, retryAfter
+	default:
+		log.Printf("queuemanager: endpoint %s returned non-retryable status %d", sh.cfg.Name, resp.StatusCode)
+		return false, false, 0
+	}
+}
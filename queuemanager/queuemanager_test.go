@@ -0,0 +1,118 @@
+package queuemanager
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// decodeWriteRequest reverses the snappy+protobuf encoding send() applies,
+// so tests can assert on what actually reached the endpoint.
+func decodeWriteRequest(t *testing.T, body []byte) *prompb.WriteRequest {
+	t.Helper()
+	decompressed, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("failed to snappy-decode request body: %v", err)
+	}
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(decompressed, &req); err != nil {
+		t.Fatalf("failed to unmarshal WriteRequest: %v", err)
+	}
+	return &req
+}
+
+func TestManagerFlushesOnBatchSize(t *testing.T) {
+	var mu sync.Mutex
+	var received []*prompb.WriteRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		received = append(received, decodeWriteRequest(t, body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := NewManager([]EndpointConfig{{Name: "test", URL: server.URL, BatchSize: 2}})
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx)
+	defer cancel()
+
+	now := time.Unix(1700000000, 0)
+	m.Enqueue(Sample{Labels: map[string]string{"server_short_name": "a"}, Value: 1, Timestamp: now})
+	m.Enqueue(Sample{Labels: map[string]string{"server_short_name": "b"}, Value: 2, Timestamp: now})
+
+	deadline := time.After(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= 1 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for batch to be flushed on reaching batch size")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received[0].Timeseries) != 2 {
+		t.Fatalf("expected 2 timeseries in the flushed batch, got %d", len(received[0].Timeseries))
+	}
+}
+
+// TestManagerDrainsOnShutdown reproduces the shutdown-drain bug: cancelling
+// the context that Start was given must still deliver whatever batch is
+// buffered, not fail it with "context canceled".
+func TestManagerDrainsOnShutdown(t *testing.T) {
+	requests := make(chan *prompb.WriteRequest, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		requests <- decodeWriteRequest(t, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// A batch size far larger than what we enqueue so the sample is only
+	// ever flushed by the shutdown drain, never by hitting batch size.
+	m := NewManager([]EndpointConfig{{Name: "test", URL: server.URL, BatchSize: 500}})
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx)
+
+	m.Enqueue(Sample{Labels: map[string]string{"server_short_name": "a"}, Value: 1, Timestamp: time.Unix(1700000000, 0)})
+
+	cancel()
+	m.Wait()
+
+	select {
+	case req := <-requests:
+		if len(req.Timeseries) != 1 {
+			t.Fatalf("expected 1 timeseries in the drained batch, got %d", len(req.Timeseries))
+		}
+	default:
+		t.Fatal("shutdown drain never delivered the buffered sample to the endpoint")
+	}
+}